@@ -0,0 +1,207 @@
+// Command x8h serves a front page of tracked Hacker News stories,
+// annotated with the visitor's country via a local MaxMind GeoLite2-City
+// database.
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Sankar-Rajendran/x8h/internal/geoip"
+	"github.com/Sankar-Rajendran/x8h/internal/hn"
+	"github.com/Sankar-Rajendran/x8h/internal/httpcache"
+	"github.com/Sankar-Rajendran/x8h/internal/logger"
+	"github.com/Sankar-Rajendran/x8h/internal/server"
+	"github.com/Sankar-Rajendran/x8h/internal/storage"
+)
+
+const (
+	defaultPort            = 8080
+	rateLimit              = "5-M"
+	humanTrackingLimit     = 300
+	frontPageNumArticles   = hn.FrontPageSize
+	hnPollTime             = 1 * time.Minute
+	mmdbRefreshInterval    = 24 * time.Hour
+	defaultSnapshotMinutes = 5
+)
+
+var version string
+
+var appLog = logger.New("app")
+
+func main() {
+	var port int
+	envPort := os.Getenv("PORT")
+	if envPort == "" {
+		port = defaultPort
+	} else {
+		var err error
+		port, err = strconv.Atoi(envPort)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	templateFile := os.Getenv("TMPL_PATH")
+	if templateFile == "" {
+		templateFile = "./index.html"
+	}
+
+	inputFilePath := os.Getenv("INPUT_PATH")
+	if inputFilePath == "" {
+		inputFilePath = "./input.json"
+	}
+
+	outputFilePath := os.Getenv("OUTPUT_PATH")
+	if outputFilePath == "" {
+		outputFilePath = "./output.json"
+	}
+
+	snapshotMinutes := defaultSnapshotMinutes
+	if envSnapshot := os.Getenv("SNAPSHOT_INTERVAL"); envSnapshot != "" {
+		var err error
+		snapshotMinutes, err = strconv.Atoi(envSnapshot)
+		if err != nil {
+			panic(err)
+		}
+	}
+	snapshotInterval := time.Duration(snapshotMinutes) * time.Minute
+
+	tmpl, err := template.New("index.html").ParseFiles(templateFile)
+	if err != nil {
+		panic(err)
+	}
+
+	httpClient := &http.Client{Transport: httpcache.New(http.DefaultTransport, httpcache.DefaultMaxEntries)}
+
+	appCtx, cancel := context.WithCancel(context.Background())
+
+	geo, err := geoip.New(appCtx, "GeoLite2-City.mmdb", os.Getenv("MAXMIND_KEY"), httpClient)
+	if err != nil {
+		panic(err)
+	}
+
+	queue := storage.NewQueue(humanTrackingLimit)
+	if err := queue.Load(outputFilePath); err != nil {
+		appLog.Warnf("loading snapshot from %s: %v", outputFilePath, err)
+	}
+
+	fetcher := hn.NewFetcher(httpClient, queue, inputFilePath)
+
+	handler, srvHandler, err := server.New(queue, geo, tmpl, version, rateLimit)
+	if err != nil {
+		panic(err)
+	}
+	http.Handle("/", handler)
+
+	// healthz/readyz/metrics are registered outside handler, so they
+	// bypass its rate limiter entirely.
+	//
+	// /healthz is deliberately a liveness probe only (mmdb open, which
+	// is already true by the time we get here) rather than also gating
+	// on the first poll: a container orchestrator reacts to a failed
+	// liveness probe by killing and restarting the process, which is
+	// the wrong response to a merely slow or still-in-progress poll.
+	// /readyz carries that gate instead, so a load balancer withholds
+	// traffic until there's actually something to serve without
+	// flapping the process itself.
+	health := server.NewHealth()
+	http.Handle("/healthz", health.LivezHandler())
+	http.Handle("/readyz", health.ReadyzHandler())
+	http.Handle("/metrics", promhttp.Handler())
+
+	appLog.Infof("START")
+	appLog.Infof("starting the app")
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, os.Kill)
+
+	intervalTicker := time.NewTicker(hnPollTime)
+
+	snapshotTicker := time.NewTicker(snapshotInterval)
+
+	go func() {
+		for range snapshotTicker.C {
+			if err := queue.Save(outputFilePath); err != nil {
+				appLog.Warnf("saving snapshot to %s: %v", outputFilePath, err)
+			}
+		}
+	}()
+
+	go func() {
+		for range intervalTicker.C {
+			appLog.Debugf("starting ticker ticker")
+			eg, ctx := errgroup.WithContext(appCtx)
+			eg.Go(func() error { return fetcher.Poll(ctx, frontPageNumArticles) })
+			eg.Go(func() error { return fetcher.FetchFromFile(ctx) })
+			eg.Go(func() error { return fetcher.RemoveStale(ctx, hn.MaxAge) })
+			if err := eg.Wait(); err != nil {
+				appLog.Warnf("poll cycle: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		for c := range fetcher.Changes() {
+			appLog.Infof("%s", c)
+		}
+	}()
+
+	go func() {
+		for err := range srvHandler.Errs() {
+			appLog.Errorf("%v", err)
+		}
+	}()
+
+	appLog.Infof("starting mmdb downloader")
+	go geo.StartBackgroundRefresh(appCtx, mmdbRefreshInterval)
+
+	appLog.Infof("starting top stories fetcher")
+	go func() {
+		if err := fetcher.Poll(appCtx, frontPageNumArticles); err != nil {
+			appLog.Warnf("initial poll: %v", err)
+		}
+		health.MarkReady()
+	}()
+
+	go func() {
+		if err := fetcher.FetchFromFile(appCtx); err != nil {
+			appLog.Warnf("reading stories from file: %v", err)
+		}
+	}()
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port)}
+
+	go func() {
+		appLog.Infof("%v", srv.ListenAndServe())
+	}()
+	sig := <-stop
+	appLog.Infof("interrupted with signal %s, aborting", sig.String())
+
+	shutdownCtx, c := context.WithTimeout(appCtx, 2*time.Second)
+	defer c()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		appLog.Warnf("shutting down server: %v", err)
+	}
+
+	appLog.Infof("clean up")
+	cancel()
+	intervalTicker.Stop()
+	snapshotTicker.Stop()
+	appLog.Infof("clean up done")
+
+	if err := queue.Save(outputFilePath); err != nil {
+		appLog.Warnf("saving snapshot to %s: %v", outputFilePath, err)
+	}
+
+	appLog.Infof("END")
+}
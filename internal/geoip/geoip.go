@@ -0,0 +1,323 @@
+// Package geoip owns the local MaxMind GeoLite2-City database: bootstrapping
+// it on first boot, refreshing it from MaxMind's license-key download API on
+// a schedule, and serving IP lookups against whichever copy is currently
+// loaded.
+package geoip
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+
+	"github.com/Sankar-Rajendran/x8h/internal/logger"
+	"github.com/Sankar-Rajendran/x8h/internal/metrics"
+)
+
+const (
+	// downloadURL is the license-key permalink API that replaced the
+	// direct mmdb.gz download MaxMind sunset in 2019.
+	downloadURL = "https://download.maxmind.com/app/geoip_download"
+	editionID   = "GeoLite2-City"
+	suffix      = "tar.gz"
+	shaSuffix   = "tar.gz.sha256"
+
+	downloadRetries     = 5
+	downloadBaseBackoff = 1 * time.Second
+)
+
+var log = logger.New("geo")
+var mmdbLog = logger.New("mmdb")
+
+// City is an alias so callers don't need to import geoip2 directly.
+type City = geoip2.City
+
+// Service serves IP-to-city lookups against a GeoLite2-City database,
+// bootstrapping it on first use and swapping in a fresh copy whenever
+// Refresh succeeds.
+type Service struct {
+	client     *http.Client
+	licenseKey string
+
+	mu            sync.RWMutex
+	reader        *geoip2.Reader
+	path          string
+	downloadCount int
+}
+
+// New opens the mmdb at path, downloading it first via MAXMIND_KEY's
+// license key if it doesn't exist yet, so the container is self
+// contained rather than requiring an out-of-band download.
+func New(ctx context.Context, path, maxMindKey string, client *http.Client) (*Service, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	licenseKey := maxMindKey
+	if idx := strings.IndexByte(maxMindKey, ':'); idx >= 0 {
+		licenseKey = maxMindKey[idx+1:]
+	}
+
+	svc := &Service{client: client, licenseKey: licenseKey, path: path}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		mmdbLog.Infof("mmdb missing on boot, bootstrapping from MaxMind")
+		if err := svc.download(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	svc.reader = reader
+
+	return svc, nil
+}
+
+// Lookup resolves an IP to a City record using whichever mmdb is
+// currently loaded.
+func (s *Service) Lookup(ip net.IP) (*City, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	city, err := s.reader.City(ip)
+	if err != nil {
+		log.Debugf("looking up %s: %v", ip, err)
+	} else {
+		log.Debugf("%s -> %s", ip, city.Country.IsoCode)
+	}
+	return city, err
+}
+
+// Refresh downloads the latest mmdb, swaps it in, and removes the
+// previous copy.
+func (s *Service) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	downloadCount := s.downloadCount
+	oldPath := s.path
+	s.mu.Unlock()
+
+	newPath := fmt.Sprintf("GeoLite2-City-%d.mmdb", downloadCount+1)
+	if err := s.download(ctx, newPath); err != nil {
+		metrics.MmdbRefreshFailure.Inc()
+		return err
+	}
+
+	reader, err := geoip2.Open(newPath)
+	if err != nil {
+		metrics.MmdbRefreshFailure.Inc()
+		return err
+	}
+
+	s.mu.Lock()
+	previous := s.reader
+	s.reader = reader
+	s.path = newPath
+	s.downloadCount = downloadCount + 1
+	s.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+
+	if err := os.Remove(oldPath); err != nil {
+		mmdbLog.Warnf("removing stale mmdb %s: %v", oldPath, err)
+	}
+
+	metrics.MmdbRefreshSuccess.Inc()
+	mmdbLog.Infof("refreshed mmdb to %s", newPath)
+	return nil
+}
+
+// StartBackgroundRefresh refreshes the mmdb on interval until ctx is
+// cancelled. It's meant to run in its own goroutine.
+func (s *Service) StartBackgroundRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				mmdbLog.Warnf("refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) maxMindURL(sfx string) string {
+	v := url.Values{}
+	v.Set("edition_id", editionID)
+	v.Set("license_key", s.licenseKey)
+	v.Set("suffix", sfx)
+	return downloadURL + "?" + v.Encode()
+}
+
+func (s *Service) download(ctx context.Context, destPath string) error {
+	return retryWithBackoff(ctx, downloadRetries, downloadBaseBackoff, func() error {
+		shaReq, err := http.NewRequest(http.MethodGet, s.maxMindURL(shaSuffix), nil)
+		if err != nil {
+			return err
+		}
+		shaResp, err := s.client.Do(shaReq.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer shaResp.Body.Close()
+		if shaResp.StatusCode != http.StatusOK {
+			return &httpStatusError{status: shaResp.StatusCode}
+		}
+		sidecar, err := ioutil.ReadAll(shaResp.Body)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodGet, s.maxMindURL(suffix), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return &httpStatusError{status: resp.StatusCode}
+		}
+
+		archive, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+
+		if err := verifySha256(archive, sidecar); err != nil {
+			return err
+		}
+
+		tmpPath := destPath + ".tmp"
+		if err := extractMmdb(bytes.NewReader(archive), tmpPath); err != nil {
+			return err
+		}
+
+		return os.Rename(tmpPath, destPath)
+	})
+}
+
+// httpStatusError marks a non-2xx HTTP response so retryWithBackoff can
+// tell a transient 5xx apart from a permanent error like a bad license
+// key.
+type httpStatusError struct {
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.status)
+}
+
+func isRetryableDownloadErr(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryWithBackoff retries fn on transient errors until it succeeds,
+// ctx is cancelled, or attempts are exhausted, doubling the delay
+// between tries. A permanent error (e.g. a 401/403 from a bad license
+// key) returns immediately instead of burning the full retry budget.
+func retryWithBackoff(ctx context.Context, attempts int, base time.Duration, fn func() error) error {
+	var err error
+	backoff := base
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil || !isRetryableDownloadErr(err) {
+			return err
+		}
+		if i == attempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// verifySha256 checks the raw .tar.gz archive bytes against the
+// "<hex>  <filename>" sidecar MaxMind serves alongside every download
+// (the sidecar covers the compressed archive, not the .mmdb it
+// contains).
+func verifySha256(archive, sidecar []byte) error {
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty sha256 sidecar")
+	}
+	want := fields[0]
+
+	sum := sha256.Sum256(archive)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("sha256 mismatch: got %s want %s", got, want)
+	}
+	return nil
+}
+
+// extractMmdb pulls the single .mmdb entry out of the tar.gz archive
+// MaxMind ships and writes it to destPath.
+func extractMmdb(r io.Reader, destPath string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("no .mmdb file found in archive")
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(hdr.Name, ".mmdb") {
+			continue
+		}
+
+		out, err := os.Create(destPath)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, tr)
+		return err
+	}
+}
@@ -0,0 +1,389 @@
+// Package hn polls Hacker News for front-page stories, merges in any
+// server-supplied stories from a local file, and retires stories that
+// have fallen off both lists.
+package hn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sankar-Rajendran/x8h/internal/logger"
+	"github.com/Sankar-Rajendran/x8h/internal/metrics"
+)
+
+// storyLink is a var, not a const, so tests can point it at an
+// httptest.Server.
+var storyLink = "https://hacker-news.firebaseio.com/v0/item/%d.json"
+
+const (
+	topStoriesURL = "https://hacker-news.firebaseio.com/v0/topstories.json"
+	postLink      = "https://news.ycombinator.com/item?id=%d"
+
+	// FrontPageSize is how many of the current top stories are tracked.
+	FrontPageSize = 30
+
+	// MaxAge is how long a story is kept once it's fallen off both the
+	// HN front page and the file feed.
+	MaxAge = 8 * time.Hour
+
+	// FromFile and FromHN identify where an Item came from.
+	FromFile = "file"
+	FromHN   = "hn"
+
+	workerPoolSize = 8
+
+	fetchRetries     = 4
+	fetchBaseBackoff = 500 * time.Millisecond
+	fetchMaxJitter   = 60 * time.Second
+)
+
+var log = logger.New("hn")
+
+// Item is a single Hacker News story, whether sourced from the live API
+// or from the local input file.
+type Item struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Domain      string `json:"domain,omitempty"`
+	From        string `json:"from,omitempty"`
+	Added       int64  `json:"added,omitempty"`
+	DiscussLink string `json:"discussLink,omitempty"`
+}
+
+type itemList []int
+
+// Action describes what happened to an Item.
+type Action string
+
+const (
+	ActionAdd    Action = "added"
+	ActionRemove Action = "removed"
+)
+
+// Change is emitted on Fetcher.Changes() whenever an Item is added to
+// or retired from the tracked set.
+type Change struct {
+	Action Action
+	Item   *Item
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s : %d", c.Action, c.Item.ID)
+}
+
+// Queue is the subset of storage.Queue that Fetcher needs — an
+// interface at the package boundary so Fetcher can be tested with a
+// fake.
+type Queue interface {
+	Add(item *Item) (removed *Item)
+	RemoveStale(stillFresh func(id int, it *Item) bool, maxAge time.Duration) []*Item
+}
+
+// Fetcher polls Hacker News and the local input file, feeding Items
+// into queue and reporting every Add/Remove on its Changes channel.
+type Fetcher struct {
+	client        *http.Client
+	queue         Queue
+	inputFilePath string
+	domains       *strings.Replacer
+
+	changes chan Change
+}
+
+// NewFetcher builds a Fetcher that reads server-supplied stories from
+// inputFilePath and stores tracked stories in queue.
+func NewFetcher(client *http.Client, queue Queue, inputFilePath string) *Fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Fetcher{
+		client:        client,
+		queue:         queue,
+		inputFilePath: inputFilePath,
+		domains:       strings.NewReplacer("http://", "", "https://", "", "www.", "", "www2.", "", "www3.", ""),
+		changes:       make(chan Change),
+	}
+}
+
+// Changes returns the channel Add/Remove events are published on.
+func (f *Fetcher) Changes() <-chan Change {
+	return f.changes
+}
+
+// Close shuts down the Changes channel. Callers must ensure no fetch is
+// still in flight.
+func (f *Fetcher) Close() {
+	close(f.changes)
+}
+
+func (f *Fetcher) urlToDomain(link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) >= 2 {
+		return parts[len(parts)-2] + "." + parts[len(parts)-1], nil
+	}
+
+	return f.domains.Replace(u.Hostname()), nil
+}
+
+func (f *Fetcher) fetchTopStories(ctx context.Context, limit int) ([]int, error) {
+	req, err := http.NewRequest(http.MethodGet, topStoriesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ids itemList
+	if err := json.NewDecoder(resp.Body).Decode(&ids); err != nil {
+		return nil, err
+	}
+	if len(ids) < limit {
+		limit = len(ids)
+	}
+
+	return ids[:limit], nil
+}
+
+func (f *Fetcher) fetchStoriesFromFile() ([]*Item, error) {
+	file, err := os.Open(f.inputFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var items []*Item
+	if err := json.NewDecoder(file).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FetchFromFile reads inputFilePath and feeds every story into the
+// queue, tagging it FromFile unless it already names a source.
+func (f *Fetcher) FetchFromFile(ctx context.Context) error {
+	items, err := f.fetchStoriesFromFile()
+	if err != nil {
+		return err
+	}
+
+	for _, it := range items {
+		if it.From == "" {
+			it.From = FromFile
+		}
+		f.ingest(it)
+	}
+
+	return nil
+}
+
+// Poll fetches the current top `limit` stories and feeds each one
+// through a bounded worker pool, so a poll cycle isn't serialized
+// behind a single slow item.
+func (f *Fetcher) Poll(ctx context.Context, limit int) error {
+	ids, err := f.fetchTopStories(ctx, limit)
+	if err != nil {
+		return err
+	}
+
+	idCh := make(chan int)
+	go func() {
+		defer close(idCh)
+		for _, id := range ids {
+			select {
+			case <-ctx.Done():
+				return
+			case idCh <- id:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workerPoolSize; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range idCh {
+				if ctx.Err() != nil {
+					return
+				}
+
+				item, err := f.fetchItemWithRetry(ctx, id)
+				if err != nil {
+					log.Warnf("fetching item %d: %v", id, err)
+					continue
+				}
+				f.ingest(item)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (f *Fetcher) ingest(item *Item) {
+	if item.Added == 0 {
+		item.Added = time.Now().Unix()
+	}
+	if item.Domain == "" {
+		if domain, err := f.urlToDomain(item.URL); err == nil {
+			item.Domain = domain
+		} else {
+			log.Warnf("deriving domain for item %d: %v", item.ID, err)
+		}
+	}
+	if item.From != FromFile {
+		item.DiscussLink = fmt.Sprintf(postLink, item.ID)
+	}
+
+	if removed := f.queue.Add(item); removed != nil {
+		metrics.ItemsRemoved.Inc()
+		f.changes <- Change{Action: ActionRemove, Item: removed}
+	}
+	metrics.ItemsAdded.Inc()
+	f.changes <- Change{Action: ActionAdd, Item: item}
+}
+
+// RemoveStale retires any tracked story that's no longer on the HN
+// front page (or, for file-sourced stories, the file feed) and has
+// aged past maxAge.
+func (f *Fetcher) RemoveStale(ctx context.Context, maxAge time.Duration) error {
+	topItems, err := f.fetchTopStories(ctx, FrontPageSize)
+	if err != nil {
+		return err
+	}
+
+	fileItems, err := f.fetchStoriesFromFile()
+	if err != nil {
+		return err
+	}
+
+	stillFresh := func(id int, it *Item) bool {
+		if it.From == FromFile {
+			for _, fi := range fileItems {
+				if fi.ID == id {
+					return true
+				}
+			}
+			return false
+		}
+
+		for _, tid := range topItems {
+			if tid == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, removed := range f.queue.RemoveStale(stillFresh, maxAge) {
+		metrics.ItemsRemoved.Inc()
+		f.changes <- Change{Action: ActionRemove, Item: removed}
+	}
+
+	return nil
+}
+
+// httpStatusError marks a non-2xx HTTP response so fetchItemWithRetry
+// can tell a transient 5xx apart from a permanent decode failure.
+type httpStatusError struct {
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.status)
+}
+
+func isRetryableFetchErr(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryWithJitter retries fn on retryable errors with capped exponential
+// backoff plus up to maxJitter of random jitter between attempts, so a
+// single Firebase blip doesn't silently drop a story and concurrent
+// retries don't all land in lockstep.
+func retryWithJitter(ctx context.Context, attempts int, base, maxJitter time.Duration, fn func() error) error {
+	var err error
+	backoff := base
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil || !isRetryableFetchErr(err) {
+			return err
+		}
+		if i == attempts-1 {
+			break
+		}
+
+		jitter := time.Duration(rand.Int63n(int64(maxJitter) + 1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff + jitter):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+func (f *Fetcher) fetchItem(ctx context.Context, itemID int) (*Item, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(storyLink, itemID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{status: resp.StatusCode}
+	}
+
+	var it Item
+	if err := json.NewDecoder(resp.Body).Decode(&it); err != nil {
+		return nil, err
+	}
+
+	return &it, nil
+}
+
+func (f *Fetcher) fetchItemWithRetry(ctx context.Context, itemID int) (*Item, error) {
+	defer metrics.ObserveFetchDuration(time.Now())
+
+	var it *Item
+	err := retryWithJitter(ctx, fetchRetries, fetchBaseBackoff, fetchMaxJitter, func() error {
+		var err error
+		it, err = f.fetchItem(ctx, itemID)
+		return err
+	})
+	return it, err
+}
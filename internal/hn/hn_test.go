@@ -0,0 +1,99 @@
+package hn
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func withStoryLink(t *testing.T, url string) {
+	t.Helper()
+	original := storyLink
+	storyLink = url
+	t.Cleanup(func() { storyLink = original })
+}
+
+func TestFetchItem_Success(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1,"title":"hello"}`))
+	}))
+	defer srv.Close()
+	withStoryLink(t, srv.URL+"/item/%d.json")
+
+	f := NewFetcher(http.DefaultClient, nil, "")
+	it, err := f.fetchItem(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if it.ID != 1 {
+		t.Fatalf("got ID %d, want 1", it.ID)
+	}
+}
+
+func TestFetchItem_ServerErrorIsRetryable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	withStoryLink(t, srv.URL+"/item/%d.json")
+
+	f := NewFetcher(http.DefaultClient, nil, "")
+	_, err := f.fetchItem(context.Background(), 1)
+	if err == nil {
+		t.Fatal("expected an error for a 500 response")
+	}
+	if !isRetryableFetchErr(err) {
+		t.Fatalf("expected %v to be classified as retryable", err)
+	}
+}
+
+func TestFetchItem_TimeoutIsRetryable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+	withStoryLink(t, srv.URL+"/item/%d.json")
+
+	client := &http.Client{Timeout: 5 * time.Millisecond}
+	f := NewFetcher(client, nil, "")
+	_, err := f.fetchItem(context.Background(), 1)
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if !isRetryableFetchErr(err) {
+		t.Fatalf("expected %v to be classified as retryable", err)
+	}
+}
+
+func TestRetryWithJitter_GivesUpAfterExhaustingAttempts(t *testing.T) {
+	var attempts int32
+	err := retryWithJitter(context.Background(), 3, time.Millisecond, time.Millisecond, func() error {
+		atomic.AddInt32(&attempts, 1)
+		return &httpStatusError{status: http.StatusServiceUnavailable}
+	})
+	if err == nil {
+		t.Fatal("expected an error after exhausting retries")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetryWithJitter_DoesNotRetryNonRetryableErrors(t *testing.T) {
+	var attempts int32
+	wantErr := &httpStatusError{status: http.StatusNotFound}
+	err := retryWithJitter(context.Background(), 5, time.Millisecond, time.Millisecond, func() error {
+		atomic.AddInt32(&attempts, 1)
+		return wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("got error %v, want %v", err, wantErr)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Fatalf("expected a single attempt for a non-retryable error, got %d", got)
+	}
+}
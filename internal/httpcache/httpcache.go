@@ -0,0 +1,231 @@
+// Package httpcache is a small http.RoundTripper that caches GET
+// responses by URL, replays 304 Not Modified responses from cache, and
+// honors Cache-Control max-age / Expires, so polling an endpoint that
+// rarely changes (e.g. HN's topstories.json) doesn't hit the network
+// every cycle. Memory usage is capped with an LRU over the cached URLs,
+// and bodies too large to be worth caching (e.g. geoip's mmdb archive)
+// are served straight through instead of being pinned in the cache.
+package httpcache
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries bounds the number of distinct URLs the cache holds
+// before it starts evicting the least recently used ones.
+const DefaultMaxEntries = 512
+
+// maxCacheableBodyBytes bounds the size of a single response body the
+// cache will hold onto. An entry-count LRU alone doesn't cap memory
+// when bodies range from tiny JSON to a multi-megabyte download (e.g.
+// geoip's mmdb archive): a handful of big bodies never push the entry
+// count anywhere near DefaultMaxEntries, so they'd sit in memory
+// forever. Anything bigger than this is served straight through
+// without being cached.
+const maxCacheableBodyBytes = 1 << 20 // 1 MiB
+
+type cachedResponse struct {
+	status       int
+	header       http.Header
+	body         []byte
+	etag         string
+	lastModified string
+	expires      time.Time
+}
+
+func (c *cachedResponse) fresh() bool {
+	return !c.expires.IsZero() && time.Now().Before(c.expires)
+}
+
+func (c *cachedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(c.status),
+		StatusCode:    c.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
+
+// Transport is an http.RoundTripper that wraps another one with
+// conditional-GET caching.
+type Transport struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front = most recently used
+	maxSize int
+}
+
+type lruEntry struct {
+	key   string
+	entry *cachedResponse
+}
+
+// New wraps next with a cache capped at maxSize URLs. If next is nil,
+// http.DefaultTransport is used. If maxSize <= 0, DefaultMaxEntries is
+// used.
+func New(next http.RoundTripper, maxSize int) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxEntries
+	}
+	return &Transport{
+		next:    next,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		maxSize: maxSize,
+	}
+}
+
+func (t *Transport) get(key string) (*cachedResponse, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	el, ok := t.entries[key]
+	if !ok {
+		return nil, false
+	}
+	t.order.MoveToFront(el)
+	return el.Value.(*lruEntry).entry, true
+}
+
+func (t *Transport) put(key string, entry *cachedResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if el, ok := t.entries[key]; ok {
+		t.order.MoveToFront(el)
+		el.Value.(*lruEntry).entry = entry
+		return
+	}
+
+	el := t.order.PushFront(&lruEntry{key: key, entry: entry})
+	t.entries[key] = el
+
+	for t.order.Len() > t.maxSize {
+		oldest := t.order.Back()
+		if oldest == nil {
+			break
+		}
+		t.order.Remove(oldest)
+		delete(t.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// RoundTrip serves a fresh cache hit directly, revalidates a stale one
+// with If-None-Match/If-Modified-Since, and otherwise falls through to
+// next, caching whatever comes back.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+
+	cached, ok := t.get(key)
+	if !ok {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		return t.cacheAndReturn(key, resp)
+	}
+
+	if cached.fresh() {
+		return cached.toResponse(req), nil
+	}
+
+	req = req.Clone(req.Context())
+	if cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		t.put(key, withExpiry(cached, resp.Header))
+		return cached.toResponse(req), nil
+	}
+
+	return t.cacheAndReturn(key, resp)
+}
+
+func (t *Transport) cacheAndReturn(key string, resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) <= maxCacheableBodyBytes {
+		t.put(key, &cachedResponse{
+			status:       resp.StatusCode,
+			header:       resp.Header.Clone(),
+			body:         body,
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+			expires:      cacheExpiry(resp.Header),
+		})
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func withExpiry(cached *cachedResponse, header http.Header) *cachedResponse {
+	updated := *cached
+	if exp := cacheExpiry(header); !exp.IsZero() {
+		updated.expires = exp
+	}
+	return &updated
+}
+
+// cacheExpiry honors Cache-Control: max-age first, falling back to
+// Expires, per RFC 7234.
+func cacheExpiry(header http.Header) time.Time {
+	if cc := header.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.TrimSpace(directive)
+			if !strings.HasPrefix(directive, "max-age=") {
+				continue
+			}
+			if maxAge, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				return time.Now().Add(time.Duration(maxAge) * time.Second)
+			}
+		}
+	}
+
+	if exp := header.Get("Expires"); exp != "" {
+		if t, err := http.ParseTime(exp); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
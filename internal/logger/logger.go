@@ -0,0 +1,65 @@
+// Package logger is a small Syncthing-style leveled logger: Infof/Warnf
+// always print, Debugf/Debugln only print when their facility is
+// enabled via the X8H_TRACE env var (e.g. X8H_TRACE=hn,geo or
+// X8H_TRACE=all), so individual subsystems can be traced without
+// recompiling.
+package logger
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+var enabledFacilities = parseTrace(os.Getenv("X8H_TRACE"))
+
+func parseTrace(v string) map[string]bool {
+	enabled := make(map[string]bool)
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			enabled[f] = true
+		}
+	}
+	return enabled
+}
+
+// Logger logs on behalf of a single named facility.
+type Logger struct {
+	facility string
+	debug    bool
+}
+
+// New returns a Logger for the given facility, with Debug output
+// enabled if facility (or "all") is listed in X8H_TRACE.
+func New(facility string) *Logger {
+	return &Logger{
+		facility: facility,
+		debug:    enabledFacilities["all"] || enabledFacilities[facility],
+	}
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	log.Printf("INFO  ["+l.facility+"] "+format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	log.Printf("WARN  ["+l.facility+"] "+format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	log.Printf("ERROR ["+l.facility+"] "+format, args...)
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	if !l.debug {
+		return
+	}
+	log.Printf("DEBUG ["+l.facility+"] "+format, args...)
+}
+
+func (l *Logger) Debugln(args ...interface{}) {
+	if !l.debug {
+		return
+	}
+	log.Println(append([]interface{}{"DEBUG", "[" + l.facility + "]"}, args...)...)
+}
@@ -0,0 +1,73 @@
+// Package metrics holds the Prometheus collectors the rest of the app
+// records against, so every package that wants to report something
+// imports this one rather than reaching into a shared registry.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ItemsAdded and ItemsRemoved count Change events as they're
+	// emitted on hn.Fetcher.Changes().
+	ItemsAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "x8h_hn_items_added_total",
+		Help: "Hacker News items added to the tracked queue.",
+	})
+	ItemsRemoved = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "x8h_hn_items_removed_total",
+		Help: "Hacker News items retired from the tracked queue.",
+	})
+
+	// MmdbRefreshSuccess and MmdbRefreshFailure count geoip.Service
+	// refresh attempts.
+	MmdbRefreshSuccess = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "x8h_mmdb_refresh_success_total",
+		Help: "Successful MaxMind mmdb refreshes.",
+	})
+	MmdbRefreshFailure = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "x8h_mmdb_refresh_failure_total",
+		Help: "Failed MaxMind mmdb refreshes.",
+	})
+
+	// FetchLatency tracks how long a single HN item fetch (including
+	// retries) takes.
+	FetchLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "x8h_hn_fetch_item_duration_seconds",
+		Help:    "Time to fetch a single Hacker News item, including retries.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// RateLimitRejections counts requests the stdlib.Middleware
+	// rejected with 429.
+	RateLimitRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "x8h_rate_limit_rejections_total",
+		Help: "Requests rejected by the rate limiter.",
+	})
+
+	// VisitCount mirrors server.Handler's running visit count.
+	VisitCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "x8h_visit_count",
+		Help: "Total requests served to the front page.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		ItemsAdded,
+		ItemsRemoved,
+		MmdbRefreshSuccess,
+		MmdbRefreshFailure,
+		FetchLatency,
+		RateLimitRejections,
+		VisitCount,
+	)
+}
+
+// ObserveFetchDuration records how long a fetch that started at start
+// took.
+func ObserveFetchDuration(start time.Time) {
+	FetchLatency.Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Health gates readiness: it starts false and flips to true once the
+// caller's first HN poll has completed. A Health is only constructed
+// once the mmdb is already open, so liveness has nothing left to gate
+// on.
+type Health struct {
+	mu    sync.Mutex
+	ready bool
+}
+
+// NewHealth returns a Health that reports not-ready until MarkReady is
+// called.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// MarkReady flips the gate, making ReadyzHandler report 200.
+func (h *Health) MarkReady() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = true
+}
+
+// IsReady reports whether MarkReady has been called.
+func (h *Health) IsReady() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ready
+}
+
+// LivezHandler is a liveness probe: it reports 200 as soon as it's
+// wired up, since by then the mmdb is already open and the process is
+// healthy enough to keep running. A slow or wedged first poll should
+// not get the pod killed — that's what ReadyzHandler is for.
+func (h *Health) LivezHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+}
+
+// ReadyzHandler is a readiness probe: it reports 503 until the first
+// HN poll completes, so a load balancer doesn't send traffic to a pod
+// with an empty story queue.
+func (h *Health) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.IsReady() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+}
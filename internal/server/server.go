@@ -0,0 +1,144 @@
+// Package server owns the rate-limited HTTP handler that renders the
+// tracked story list.
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/middleware/stdlib"
+	sim "github.com/ulule/limiter/v3/drivers/store/memory"
+
+	"github.com/Sankar-Rajendran/x8h/internal/geoip"
+	"github.com/Sankar-Rajendran/x8h/internal/hn"
+	"github.com/Sankar-Rajendran/x8h/internal/logger"
+	"github.com/Sankar-Rajendran/x8h/internal/metrics"
+)
+
+const (
+	headerXForwardedFor = "X-Forwarded-For"
+	headerXRealIP       = "X-Real-IP"
+)
+
+var log = logger.New("ratelimit")
+
+// Queue is the subset of storage.Queue that Handler needs to render a
+// page.
+type Queue interface {
+	Snapshot() map[int]*hn.Item
+}
+
+type visitCounter struct {
+	sync.Mutex
+	count int
+}
+
+// Handler renders the tracked story list behind a rate limiter.
+type Handler struct {
+	queue   Queue
+	geo     *geoip.Service
+	tmpl    *template.Template
+	version string
+
+	visits visitCounter
+	errs   chan error
+}
+
+// New builds a Handler wrapped in a rate limiter allowing `rate`
+// requests (ulule/limiter format, e.g. "5-M") per client.
+func New(queue Queue, geo *geoip.Service, tmpl *template.Template, version, rate string) (http.Handler, *Handler, error) {
+	limiterRate, err := limiter.NewRateFromFormatted(rate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := &Handler{
+		queue:   queue,
+		geo:     geo,
+		tmpl:    tmpl,
+		version: version,
+		errs:    make(chan error),
+	}
+
+	store := sim.NewStore()
+	middleware := stdlib.NewMiddleware(limiter.New(store, limiterRate, limiter.WithTrustForwardHeader(true)))
+
+	return countRejections(middleware.Handler(h)), h, nil
+}
+
+// statusRecorder captures the status code a wrapped handler writes, so
+// countRejections can tell a 429 apart from anything else without
+// reimplementing the rate limiter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// countRejections wraps next, incrementing metrics.RateLimitRejections
+// whenever the stdlib.Middleware rejects a request with 429.
+func countRejections(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status == http.StatusTooManyRequests {
+			metrics.RateLimitRejections.Inc()
+		}
+	})
+}
+
+// Errs reports fatal per-request errors (currently just template
+// rendering failures) for the caller to log.
+func (h *Handler) Errs() <-chan error {
+	return h.errs
+}
+
+func realIP(r *http.Request) string {
+	ra := r.RemoteAddr
+	if ip := r.Header.Get(headerXForwardedFor); ip != "" {
+		ra = strings.Split(ip, ", ")[0]
+	} else if ip := r.Header.Get(headerXRealIP); ip != "" {
+		ra = ip
+	} else {
+		ra, _, _ = net.SplitHostPort(ra)
+	}
+
+	return ra
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	possibleIP := realIP(r)
+	log.Debugf("request from %s (%s)", possibleIP, r.UserAgent())
+
+	ip := net.ParseIP(possibleIP)
+	city, _ := h.geo.Lookup(ip)
+
+	data := make(map[string]interface{})
+	data["Data"] = h.queue.Snapshot()
+	if city != nil {
+		data["Country"] = city.Country.IsoCode
+	}
+
+	h.visits.Lock()
+	h.visits.count++
+	visits := h.visits.count
+	h.visits.Unlock()
+
+	data["Visits"] = visits
+	metrics.VisitCount.Set(float64(visits))
+
+	data["Version"] = h.version
+
+	if err := h.tmpl.Execute(w, data); err != nil {
+		h.errs <- fmt.Errorf("rendering template: %w", err)
+	}
+}
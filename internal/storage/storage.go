@@ -0,0 +1,146 @@
+// Package storage owns the in-memory queue of tracked Hacker News
+// stories and its on-disk JSON snapshot.
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sankar-Rajendran/x8h/internal/hn"
+)
+
+// Queue keeps the most recent `limit` items, evicting the oldest by
+// insertion order once that limit is reached.
+type Queue struct {
+	mu    sync.Mutex
+	limit int
+	keys  []int
+	store map[int]*hn.Item
+}
+
+// NewQueue returns an empty Queue that tracks at most limit items.
+func NewQueue(limit int) *Queue {
+	return &Queue{
+		limit: limit,
+		keys:  []int{},
+		store: make(map[int]*hn.Item),
+	}
+}
+
+// Add inserts item, evicting and returning the oldest tracked item if
+// the queue was already at its limit. Re-adding an already-tracked ID
+// updates it in place without evicting anything.
+func (q *Queue) Add(item *hn.Item) *hn.Item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.store[item.ID]; exists {
+		q.store[item.ID] = item
+		return nil
+	}
+
+	var removed *hn.Item
+	if len(q.keys) >= q.limit {
+		oldest := q.keys[0]
+		q.keys = q.keys[1:]
+		removed = q.store[oldest]
+		delete(q.store, oldest)
+	}
+
+	q.keys = append(q.keys, item.ID)
+	q.store[item.ID] = item
+
+	return removed
+}
+
+// RemoveStale removes every tracked item for which stillFresh returns
+// false and that has aged past maxAge, returning the removed items.
+func (q *Queue) RemoveStale(stillFresh func(id int, it *hn.Item) bool, maxAge time.Duration) []*hn.Item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var removed []*hn.Item
+	for id, it := range q.store {
+		if stillFresh(id, it) {
+			continue
+		}
+		if time.Since(time.Unix(it.Added, 0)) <= maxAge {
+			continue
+		}
+		removed = append(removed, it)
+		q.removeLocked(id)
+	}
+	return removed
+}
+
+func (q *Queue) removeLocked(id int) {
+	delete(q.store, id)
+	for i, key := range q.keys {
+		if key == id {
+			q.keys = append(q.keys[:i], q.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Snapshot returns a point-in-time copy of the tracked items, keyed by
+// ID, safe to hand to a template renderer without holding the queue's
+// lock for the duration.
+func (q *Queue) Snapshot() map[int]*hn.Item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	snapshot := make(map[int]*hn.Item, len(q.store))
+	for id, it := range q.store {
+		snapshot[id] = it
+	}
+	return snapshot
+}
+
+// Save atomically writes the current snapshot to path (write to a
+// tempfile in the same directory, then rename) so a crash mid-write
+// can't corrupt the file a restart would load.
+func (q *Queue) Save(path string) error {
+	data, err := json.Marshal(q.Snapshot())
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load replaces the queue's contents with the snapshot at path. It's a
+// no-op if path doesn't exist, so a first boot with no prior snapshot
+// just starts empty. The snapshot doesn't record insertion order, so
+// the loaded items are queued for eviction in map iteration order,
+// not oldest first.
+func (q *Queue) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snapshot map[int]*hn.Item
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.store = snapshot
+	q.keys = q.keys[:0]
+	for id := range snapshot {
+		q.keys = append(q.keys, id)
+	}
+	return nil
+}